@@ -7,16 +7,22 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
 )
 
+// errNoFilesystem is returned by operations that need Path() when it's
+// nil, i.e. when RawPath hasn't resolved to a usable Filesystem (not
+// configured, or a "${...}" reference in it couldn't be expanded).
+var errNoFilesystem = errors.New("folder path is not resolved to a filesystem")
+
 type FolderConfiguration struct {
 	ID                    string                      `xml:"id,attr" json:"id"`
 	Label                 string                      `xml:"label,attr" json:"label"`
@@ -44,8 +50,10 @@ type FolderConfiguration struct {
 	Paused                bool                        `xml:"paused" json:"paused"`
 	TempDirPath           string                      `xml:"tempDirPath" json:"tempDirPath"`
 
-	cachedPath   string
-	cachedTmpDir string
+	resolvedPath        string
+	resolvedTempDirPath string
+	cachedFilesystem    fs.Filesystem
+	cachedTmpFilesystem fs.Filesystem
 
 	DeprecatedReadOnly bool `xml:"ro,attr,omitempty" json:"-"`
 }
@@ -72,52 +80,94 @@ func (f FolderConfiguration) Copy() FolderConfiguration {
 	return c
 }
 
-func (f FolderConfiguration) Path() string {
+// Path returns the Filesystem backing this folder, rooted at the cleaned
+// ResolvedPath(). It is nil when RawPath is empty or did not resolve.
+//
+// This is a breaking change from the previous string-returning Path():
+// every caller needs to move from plain os/filepath/osutil calls to the
+// fs.Filesystem equivalents. lib/config is the only package in this
+// source tree that calls Path(), so it's the only caller updated here; lib/model,
+// lib/scanner and any other package that calls FolderConfiguration.Path()
+// or TmpPath() in the full repository will need the same migration
+// before this lands there.
+func (f FolderConfiguration) Path() fs.Filesystem {
 	// This is intentionally not a pointer method, because things like
 	// cfg.Folders["default"].Path() should be valid.
 
-	if f.cachedPath == "" && f.RawPath != "" {
+	if f.cachedFilesystem == nil && f.RawPath != "" {
 		l.Infoln("bug: uncached path call (should only happen in tests)")
-		return f.cleanedPath()
+		// cleanedFilesystem() needs resolvedPath, which only prepare()
+		// sets; f is a copy, so preparing it leaves the caller untouched.
+		f.prepare()
+		return f.cachedFilesystem
 	}
-	return f.cachedPath
+	return f.cachedFilesystem
 }
 
-// TmpPath returns directory in which temporary files should
-// be created.
-func (f FolderConfiguration) TmpPath() string {
+// TmpPath returns the Filesystem in which temporary files should be
+// created. See the Path() doc comment for the scope of this migration.
+func (f FolderConfiguration) TmpPath() fs.Filesystem {
 	// This is intentionally not a pointer method, because things like
 	// cfg.Folders["default"].TmpDirPath() should be valid.
 
-	if f.cachedTmpDir == "" && f.TempDirPath != "" {
+	if f.cachedTmpFilesystem == nil && f.TempDirPath != "" {
 		l.Infoln("bug: uncached TmpDir call (should only happen in tests)")
-		return f.cleanedTmpPath()
+		// Go through prepare() so that an unresolved Path() also leaves
+		// TmpPath() nil and the "tmp must be under path" check applies.
+		f.prepare()
+		return f.cachedTmpFilesystem
 	}
-	return f.cachedTmpDir
+	return f.cachedTmpFilesystem
 }
 
 func (f *FolderConfiguration) CreateMarker() error {
+	if f.Path() == nil {
+		return errNoFilesystem
+	}
+
 	if !f.HasMarker() {
-		marker := filepath.Join(f.Path(), ".stfolder")
-		fd, err := os.Create(marker)
+		fd, err := f.Path().Create(".stfolder")
 		if err != nil {
 			return err
 		}
 		fd.Close()
-		if err := osutil.SyncDir(filepath.Dir(marker)); err != nil {
-			l.Infof("fsync %q failed: %v", filepath.Dir(marker), err)
+		if err := f.Path().SyncDir("."); err != nil {
+			l.Infof("fsync %q failed: %v", f.Path().URI(), err)
 		}
-		osutil.HideFile(marker)
+		f.Path().HideFile(".stfolder")
 	}
 
 	return nil
 }
 
 func (f *FolderConfiguration) HasMarker() bool {
-	_, err := os.Stat(filepath.Join(f.Path(), ".stfolder"))
+	if f.Path() == nil {
+		return false
+	}
+	_, err := f.Path().Stat(".stfolder")
 	return err == nil
 }
 
+// ResolvedPath returns RawPath with any "${...}" variables expanded. It
+// is not yet tilde-expanded or made absolute; Path().URI() holds that
+// fully cleaned location. Use this rather than RawPath when
+// displaying the effective folder location to the user; RawPath may
+// still contain the unexpanded template. Returns "" if RawPath's
+// variables could not be resolved; Path() will be nil in that case too.
+func (f FolderConfiguration) ResolvedPath() string {
+	return f.resolvedPath
+}
+
+// Rehome discards all cached path resolutions (the cache is shared by
+// every folder, so other folders' entries go too) and re-runs this
+// folder's. Call it after something that a cached Path()/TmpPath()
+// depends on has changed, e.g. the user's HOME directory becoming
+// available or a path variable's value changing.
+func (f *FolderConfiguration) Rehome() {
+	InvalidatePathCache()
+	f.prepare()
+}
+
 func (f FolderConfiguration) Description() string {
 	if f.Label == "" {
 		return f.ID
@@ -151,24 +201,47 @@ func fixPath(p string) string {
 }
 
 func (f *FolderConfiguration) prepare() {
+	// resolvedPath/resolvedTempDirPath stay empty - and Path()/TmpPath()
+	// keep returning nil - when a "${...}" reference can't be resolved.
+	// Falling back to the unexpanded template as a literal path would
+	// silently start syncing to e.g. a directory literally named
+	// "${folderID}".
+	f.resolvedPath = ""
 	if f.RawPath != "" {
-		f.RawPath = fixPath(f.RawPath)
+		if expanded, err := f.expandPathVars(f.RawPath); err != nil {
+			l.Warnf("Folder %s: %s; folder will not start until this is fixed", f.Description(), err)
+		} else {
+			f.resolvedPath = fixPath(expanded)
+		}
 	}
 
+	f.resolvedTempDirPath = ""
 	if f.TempDirPath != "" {
-		f.TempDirPath = fixPath(f.TempDirPath)
+		if expanded, err := f.expandPathVars(f.TempDirPath); err != nil {
+			l.Warnf("Folder %s: %s; folder will not start until this is fixed", f.Description(), err)
+		} else {
+			f.resolvedTempDirPath = fixPath(expanded)
+		}
 	}
 
-	f.cachedPath = f.cleanedPath()
-	f.cachedTmpDir = f.cleanedTmpPath()
-
-	// validate to make sure TmpPath is a subdirectory of RawPath
-	if f.cachedTmpDir != "" {
-		relativePath, err := filepath.Rel(f.cachedPath, f.cachedTmpDir)
-		if err != nil || filepath.HasPrefix(relativePath, "..") {
-			// if not under Path, just create temporary
-			// files under root directory
-			f.cachedTmpDir = f.cachedPath
+	f.cachedFilesystem = f.cleanedFilesystem()
+	if f.cachedFilesystem == nil {
+		// No Path(), so no usable TmpPath() either: a temp filesystem
+		// for a folder that isn't started would skip the "tmp must be
+		// under path" check below and leave a filesystem ready to write
+		// to despite RawPath never having resolved.
+		f.cachedTmpFilesystem = nil
+	} else {
+		f.cachedTmpFilesystem = f.cleanedTmpFilesystem()
+
+		// validate to make sure TmpPath is a subdirectory of RawPath
+		if f.cachedTmpFilesystem != nil {
+			relativePath, err := filepath.Rel(f.cachedFilesystem.URI(), f.cachedTmpFilesystem.URI())
+			if err != nil || filepath.HasPrefix(relativePath, "..") {
+				// if not under Path, just create temporary
+				// files under root directory
+				f.cachedTmpFilesystem = f.cachedFilesystem
+			}
 		}
 	}
 
@@ -183,18 +256,31 @@ func (f *FolderConfiguration) prepare() {
 	}
 }
 
-// cleanPath returns rawPath with tilde's expanded
-// and abolutified
+// cleanPath returns rawPath with tilde's expanded and abolutified. The
+// resolution is cached for a short while (see cachedCleanPath), so this
+// is cheap to call repeatedly with the same rawPath.
 func cleanPath(rawPath string) string {
 	if rawPath == "" {
 		return ""
 	}
 
+	cleaned, err := cachedCleanPath(rawPath)
+	if err != nil {
+		l.Debugln("cleanPath:", err)
+	}
+	return cleaned
+}
+
+// cleanPathUncached does the actual work for cleanPath, uncached.
+func cleanPathUncached(rawPath string) (string, error) {
 	cleaned := rawPath
+	var firstErr error
 
 	// Attempt tilde expansion; leave unchanged in case of error
 	if path, err := osutil.ExpandTilde(cleaned); err == nil {
 		cleaned = path
+	} else {
+		firstErr = err
 	}
 
 	// Attempt absolutification; leave unchanged in case of error
@@ -204,13 +290,15 @@ func cleanPath(rawPath string) string {
 		// somewhat faster in the general case, hence the outer if...
 		if path, err := filepath.Abs(cleaned); err == nil {
 			cleaned = path
+		} else if firstErr == nil {
+			firstErr = err
 		}
 	}
 
 	// Attempt to enable long filename support on Windows. We may still not
 	// have an absolute path here if the previous steps failed.
 	if runtime.GOOS == "windows" && filepath.IsAbs(cleaned) && !strings.HasPrefix(rawPath, `\\`) {
-		return `\\?\` + cleaned
+		return `\\?\` + cleaned, firstErr
 	}
 
 	// If we're not on Windows, we want the path to end with a slash to
@@ -219,18 +307,32 @@ func cleanPath(rawPath string) string {
 		cleaned = cleaned + string(filepath.Separator)
 	}
 
-	return cleaned
+	return cleaned, firstErr
 }
 
-func (f *FolderConfiguration) cleanedPath() string {
-	return cleanPath(f.RawPath)
+// cleanedFilesystem returns the default, local Filesystem implementation
+// rooted at the cleaned resolvedPath, or nil if that is empty (prepare()
+// has not run, or RawPath did not resolve). Other backends (sftp://, s3://, an
+// in-memory filesystem for tests, ...) can be plugged in here once they
+// implement fs.Filesystem.
+func (f *FolderConfiguration) cleanedFilesystem() fs.Filesystem {
+	path := cleanPath(f.resolvedPath)
+	if path == "" {
+		return nil
+	}
+	return fs.NewBasicFilesystem(path)
 }
 
-func (f *FolderConfiguration) cleanedTmpPath() string {
+func (f *FolderConfiguration) cleanedTmpFilesystem() fs.Filesystem {
 	if f.TempDirPath == "" {
+		// No separate temp dir configured; share the folder's Filesystem.
 		return f.Path()
 	}
-	return cleanPath(f.TempDirPath)
+	if f.resolvedTempDirPath == "" {
+		// TempDirPath was configured but failed to resolve (see prepare()).
+		return nil
+	}
+	return fs.NewBasicFilesystem(cleanPath(f.resolvedTempDirPath))
 }
 
 type FolderDeviceConfigurationList []FolderDeviceConfiguration
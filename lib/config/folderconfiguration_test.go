@@ -0,0 +1,89 @@
+// Copyright (C) 2014 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package config
+
+import "testing"
+
+func TestFolderConfigurationUnresolvablePath(t *testing.T) {
+	f := NewFolderConfiguration("default", "${notavar}/sync")
+
+	if f.ResolvedPath() != "" {
+		t.Errorf("ResolvedPath() = %q, expected \"\" for an unresolved template", f.ResolvedPath())
+	}
+	if f.Path() != nil {
+		t.Error("Path() should be nil when RawPath could not be resolved, not a literal directory named after the template")
+	}
+
+	// The whole point of keeping Path() nil is that nothing downstream
+	// gets to touch disk at the unresolved, literal template path.
+	// Exercise the marker methods too, not just Path() itself.
+	if f.HasMarker() {
+		t.Error("HasMarker() should be false for an unresolved folder")
+	}
+	if err := f.CreateMarker(); err != errNoFilesystem {
+		t.Errorf("CreateMarker() = %v, expected errNoFilesystem for an unresolved folder", err)
+	}
+}
+
+func TestHasMarkerCreateMarkerWithoutFilesystem(t *testing.T) {
+	// A zero-value FolderConfiguration (e.g. RawPath == "") has no
+	// cachedFilesystem; HasMarker/CreateMarker must not panic on it.
+	f := FolderConfiguration{ID: "default"}
+
+	if f.HasMarker() {
+		t.Error("HasMarker() should be false when there is no filesystem")
+	}
+	if err := f.CreateMarker(); err != errNoFilesystem {
+		t.Errorf("CreateMarker() = %v, expected errNoFilesystem", err)
+	}
+}
+
+func TestFolderConfigurationUnresolvableTempDir(t *testing.T) {
+	f := FolderConfiguration{
+		ID:          "default",
+		RawPath:     "/var/sync",
+		TempDirPath: "${notavar}/tmp",
+	}
+	f.prepare()
+
+	if f.Path() == nil {
+		t.Fatal("Path() should resolve fine independently of TempDirPath")
+	}
+	if f.TmpPath() != nil {
+		t.Error("TmpPath() should be nil when TempDirPath could not be resolved, not fall back to Path()")
+	}
+}
+
+func TestFolderConfigurationUnresolvablePathResolvableTempDir(t *testing.T) {
+	// RawPath fails to resolve but TempDirPath resolves fine on its own;
+	// the folder as a whole is still unusable, so TmpPath() must not
+	// hand back a filesystem independent of Path().
+	f := FolderConfiguration{
+		ID:          "default",
+		RawPath:     "${notavar}/sync",
+		TempDirPath: "/var/tmp",
+	}
+	f.prepare()
+
+	if f.Path() != nil {
+		t.Fatal("Path() should be nil when RawPath could not be resolved")
+	}
+	if f.TmpPath() != nil {
+		t.Error("TmpPath() should be nil when Path() is nil, even if TempDirPath resolves on its own")
+	}
+}
+
+func TestFolderConfigurationResolvedPath(t *testing.T) {
+	f := NewFolderConfiguration("myfolder", "/var/sync/${folderID}")
+
+	if f.ResolvedPath() == "" {
+		t.Fatal("expected RawPath with a known variable to resolve")
+	}
+	if f.Path() == nil {
+		t.Fatal("expected Path() to be non-nil once RawPath resolves")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (C) 2014 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// pathResolveTTL bounds how long a cleanPath() resolution, successful or
+// not, is trusted for. It's short enough that a HOME directory which
+// becomes available shortly after startup is picked up on the next
+// resolution, while still saving repeated filepath.Abs/tilde-expansion
+// syscalls when the same path is resolved again.
+const pathResolveTTL = 10 * time.Second
+
+type pathCacheEntry struct {
+	path    string
+	err     error
+	expires time.Time
+}
+
+var (
+	pathCacheMut sync.Mutex
+	pathCache    = make(map[string]pathCacheEntry)
+)
+
+// pathCacheKey identifies a cleanPath() resolution. Besides rawPath, the
+// current working directory and HOME both affect the result (via Abs()
+// and tilde expansion respectively), so they're part of the key.
+func pathCacheKey(rawPath string) string {
+	wd, _ := os.Getwd()
+	return rawPath + "\x00" + wd + "\x00" + os.Getenv("HOME")
+}
+
+// cachedCleanPath is cleanPathUncached with a short-TTL cache in front
+// of it. Failures (a missing HOME, a transient Abs() error) are cached
+// too, mirroring the negative caching used for failed discovery lookups
+// elsewhere in Syncthing, so that a HOME lookup failing at startup
+// doesn't get retried on every resolution of the same path. Entries are
+// keyed by the whole rawPath, so folders that merely share a root do
+// not share an entry.
+func cachedCleanPath(rawPath string) (string, error) {
+	key := pathCacheKey(rawPath)
+
+	pathCacheMut.Lock()
+	if entry, ok := pathCache[key]; ok && time.Now().Before(entry.expires) {
+		pathCacheMut.Unlock()
+		return entry.path, entry.err
+	}
+	pathCacheMut.Unlock()
+
+	path, err := cleanPathUncached(rawPath)
+
+	pathCacheMut.Lock()
+	pathCache[key] = pathCacheEntry{path: path, err: err, expires: time.Now().Add(pathResolveTTL)}
+	pathCacheMut.Unlock()
+
+	return path, err
+}
+
+// InvalidatePathCache discards all cached path resolutions. Call this
+// whenever something that cleanPath()'s result depends on may have
+// changed, e.g. on config reload or from Rehome().
+func InvalidatePathCache() {
+	pathCacheMut.Lock()
+	pathCache = make(map[string]pathCacheEntry)
+	pathCacheMut.Unlock()
+}
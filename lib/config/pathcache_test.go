@@ -0,0 +1,93 @@
+// Copyright (C) 2014 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func resetPathCache() {
+	pathCacheMut.Lock()
+	pathCache = make(map[string]pathCacheEntry)
+	pathCacheMut.Unlock()
+}
+
+func TestCachedCleanPathHit(t *testing.T) {
+	resetPathCache()
+	defer resetPathCache()
+
+	const rawPath = "/some/unlikely/test/path"
+	key := pathCacheKey(rawPath)
+
+	pathCacheMut.Lock()
+	pathCache[key] = pathCacheEntry{path: "/bogus/cached/value", err: nil, expires: time.Now().Add(pathResolveTTL)}
+	pathCacheMut.Unlock()
+
+	path, err := cachedCleanPath(rawPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if path != "/bogus/cached/value" {
+		t.Errorf("cachedCleanPath returned %q, expected the cached value to be used instead of recomputing", path)
+	}
+}
+
+func TestCachedCleanPathNegativeCaching(t *testing.T) {
+	resetPathCache()
+	defer resetPathCache()
+
+	const rawPath = "/some/other/unlikely/test/path"
+	key := pathCacheKey(rawPath)
+	wantErr := errors.New("boom")
+
+	pathCacheMut.Lock()
+	pathCache[key] = pathCacheEntry{path: "", err: wantErr, expires: time.Now().Add(pathResolveTTL)}
+	pathCacheMut.Unlock()
+
+	_, err := cachedCleanPath(rawPath)
+	if err != wantErr {
+		t.Errorf("cachedCleanPath returned error %v, expected the cached failure %v to be reused", err, wantErr)
+	}
+}
+
+func TestCachedCleanPathExpiry(t *testing.T) {
+	resetPathCache()
+	defer resetPathCache()
+
+	const rawPath = "/yet/another/unlikely/test/path"
+	key := pathCacheKey(rawPath)
+
+	pathCacheMut.Lock()
+	pathCache[key] = pathCacheEntry{path: "/bogus/cached/value", err: nil, expires: time.Now().Add(-time.Second)}
+	pathCacheMut.Unlock()
+
+	path, _ := cachedCleanPath(rawPath)
+	if path == "/bogus/cached/value" {
+		t.Error("cachedCleanPath reused an expired cache entry instead of recomputing")
+	}
+}
+
+func TestInvalidatePathCache(t *testing.T) {
+	resetPathCache()
+	defer resetPathCache()
+
+	pathCacheMut.Lock()
+	pathCache["somekey"] = pathCacheEntry{path: "/x", expires: time.Now().Add(pathResolveTTL)}
+	pathCacheMut.Unlock()
+
+	InvalidatePathCache()
+
+	pathCacheMut.Lock()
+	n := len(pathCache)
+	pathCacheMut.Unlock()
+
+	if n != 0 {
+		t.Errorf("InvalidatePathCache left %d entries behind, expected 0", n)
+	}
+}
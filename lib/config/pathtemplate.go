@@ -0,0 +1,100 @@
+// Copyright (C) 2014 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// pathVarPattern matches "${name}" style variable references in a
+// RawPath or TempDirPath, e.g. "${hostname}" or "${env:HOME}".
+var pathVarPattern = regexp.MustCompile(`\$\{([a-zA-Z0-9_]+(?::[a-zA-Z0-9_]+)?)\}`)
+
+// expandPathVars expands the host-dependent variables supported in
+// FolderConfiguration.RawPath and TempDirPath: ${hostname}, ${os},
+// ${arch}, ${user}, ${env:VARNAME} and ${folderID}. It returns an error
+// if a variable cannot be resolved, or if expansion would be recursive
+// (a resolved value itself contains an unexpanded "${...}" reference).
+func (f *FolderConfiguration) expandPathVars(raw string) (string, error) {
+	var firstErr error
+
+	expanded := pathVarPattern.ReplaceAllStringFunc(raw, func(match string) string {
+		if firstErr != nil {
+			return match
+		}
+
+		name := pathVarPattern.FindStringSubmatch(match)[1]
+
+		value, err := f.resolvePathVar(name)
+		if err != nil {
+			firstErr = err
+			return match
+		}
+		return value
+	})
+
+	if firstErr != nil {
+		return "", firstErr
+	}
+
+	// A resolved value that itself looks like "${name}" (e.g. an
+	// ${env:VARNAME} whose value contains a variable reference) would
+	// expand differently on a second pass; reject it rather than
+	// recursing. Check this before the more general "leftover ${"
+	// check below, since every recursive match also contains "${".
+	if pathVarPattern.MatchString(expanded) {
+		return "", fmt.Errorf("recursive variable expansion in path %q", raw)
+	}
+
+	if strings.Contains(expanded, "${") {
+		return "", fmt.Errorf("unresolved variable in path %q", raw)
+	}
+
+	return expanded, nil
+}
+
+func (f *FolderConfiguration) resolvePathVar(name string) (string, error) {
+	switch {
+	case name == "hostname":
+		host, err := os.Hostname()
+		if err != nil {
+			return "", fmt.Errorf("resolving ${hostname}: %s", err)
+		}
+		return host, nil
+
+	case name == "os":
+		return runtime.GOOS, nil
+
+	case name == "arch":
+		return runtime.GOARCH, nil
+
+	case name == "user":
+		u, err := user.Current()
+		if err != nil {
+			return "", fmt.Errorf("resolving ${user}: %s", err)
+		}
+		return u.Username, nil
+
+	case name == "folderID":
+		return f.ID, nil
+
+	case strings.HasPrefix(name, "env:"):
+		varName := name[len("env:"):]
+		value, ok := os.LookupEnv(varName)
+		if !ok {
+			return "", fmt.Errorf("environment variable %q is not set", varName)
+		}
+		return value, nil
+	}
+
+	return "", fmt.Errorf("unknown path variable %q", name)
+}
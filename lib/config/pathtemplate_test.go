@@ -0,0 +1,90 @@
+// Copyright (C) 2014 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestExpandPathVarsKnown(t *testing.T) {
+	f := &FolderConfiguration{ID: "myfolder"}
+
+	cases := []struct {
+		raw      string
+		expected string
+	}{
+		{"/sync/${folderID}", "/sync/myfolder"},
+		{"/sync/${os}-${arch}", "/sync/" + runtime.GOOS + "-" + runtime.GOARCH},
+	}
+
+	for _, c := range cases {
+		got, err := f.expandPathVars(c.raw)
+		if err != nil {
+			t.Errorf("expandPathVars(%q) returned unexpected error: %s", c.raw, err)
+			continue
+		}
+		if got != c.expected {
+			t.Errorf("expandPathVars(%q) = %q, want %q", c.raw, got, c.expected)
+		}
+	}
+}
+
+func TestExpandPathVarsEnv(t *testing.T) {
+	f := &FolderConfiguration{ID: "myfolder"}
+
+	os.Setenv("STCHUNK0TESTVAR", "envvalue")
+	defer os.Unsetenv("STCHUNK0TESTVAR")
+
+	got, err := f.expandPathVars("/sync/${env:STCHUNK0TESTVAR}")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != "/sync/envvalue" {
+		t.Errorf("got %q, want /sync/envvalue", got)
+	}
+}
+
+func TestExpandPathVarsUnresolved(t *testing.T) {
+	f := &FolderConfiguration{ID: "myfolder"}
+
+	_, err := f.expandPathVars("/sync/${notavar}")
+	if err == nil {
+		t.Fatal("expected an error for an unknown variable")
+	}
+	if !strings.Contains(err.Error(), "unknown path variable") {
+		t.Errorf("expected an unknown-variable error, got: %s", err)
+	}
+}
+
+func TestExpandPathVarsUnsetEnv(t *testing.T) {
+	f := &FolderConfiguration{ID: "myfolder"}
+
+	os.Unsetenv("STCHUNK0TESTVARMISSING")
+
+	_, err := f.expandPathVars("/sync/${env:STCHUNK0TESTVARMISSING}")
+	if err == nil {
+		t.Fatal("expected an error for an unset environment variable")
+	}
+}
+
+func TestExpandPathVarsRecursive(t *testing.T) {
+	f := &FolderConfiguration{ID: "myfolder"}
+
+	os.Setenv("STCHUNK0TESTVAR", "${hostname}")
+	defer os.Unsetenv("STCHUNK0TESTVAR")
+
+	_, err := f.expandPathVars("/sync/${env:STCHUNK0TESTVAR}")
+	if err == nil {
+		t.Fatal("expected an error when a resolved variable's value is itself a variable reference")
+	}
+	if !strings.Contains(err.Error(), "recursive") {
+		t.Errorf("expected a recursive-expansion error, got: %s", err)
+	}
+}
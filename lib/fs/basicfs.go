@@ -0,0 +1,73 @@
+// Copyright (C) 2014 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/syncthing/syncthing/lib/osutil"
+)
+
+// BasicFilesystem is the default Filesystem implementation, rooted at a
+// path on the local disk.
+type BasicFilesystem struct {
+	root string
+}
+
+// NewBasicFilesystem returns a Filesystem rooted at root, which is
+// expected to already be cleaned and absolute.
+func NewBasicFilesystem(root string) *BasicFilesystem {
+	return &BasicFilesystem{root: root}
+}
+
+func (f *BasicFilesystem) resolve(name string) string {
+	return filepath.Join(f.root, name)
+}
+
+func (f *BasicFilesystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(f.resolve(name))
+}
+
+func (f *BasicFilesystem) Create(name string) (File, error) {
+	return os.Create(f.resolve(name))
+}
+
+func (f *BasicFilesystem) Mkdir(name string, perm os.FileMode) error {
+	return os.Mkdir(f.resolve(name), perm)
+}
+
+func (f *BasicFilesystem) Rename(oldname, newname string) error {
+	return os.Rename(f.resolve(oldname), f.resolve(newname))
+}
+
+func (f *BasicFilesystem) SyncDir(name string) error {
+	return osutil.SyncDir(f.resolve(name))
+}
+
+func (f *BasicFilesystem) HideFile(name string) error {
+	return osutil.HideFile(f.resolve(name))
+}
+
+// Walk walks the tree rooted at root, same as filepath.Walk, except root
+// and the paths passed to walkFn are relative to the Filesystem's root
+// like everywhere else in this interface, not resolved host paths.
+func (f *BasicFilesystem) Walk(root string, walkFn filepath.WalkFunc) error {
+	return filepath.Walk(f.resolve(root), func(path string, info os.FileInfo, err error) error {
+		rel, relErr := filepath.Rel(f.root, path)
+		if relErr != nil {
+			// Shouldn't happen, path is always under f.root, but don't
+			// hand back an absolute host path if it does.
+			rel = path
+		}
+		return walkFn(rel, info, err)
+	})
+}
+
+func (f *BasicFilesystem) URI() string {
+	return f.root
+}
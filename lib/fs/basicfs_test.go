@@ -0,0 +1,66 @@
+// Copyright (C) 2014 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBasicFilesystemWalkIsRootRelative(t *testing.T) {
+	root, err := ioutil.TempDir("", "stfswalktest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "dir", "file"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	basicFS := NewBasicFilesystem(root)
+
+	var seen []string
+	err = basicFS.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		seen = append(seen, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range seen {
+		if filepath.IsAbs(path) {
+			t.Errorf("Walk callback got absolute host path %q, want a path relative to the filesystem root", path)
+		}
+	}
+
+	want := filepath.Join("dir", "file")
+	found := false
+	for _, path := range seen {
+		if path == want {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Walk did not report %q among %v", want, seen)
+	}
+
+	// The relative path must round-trip through another Filesystem
+	// method on the same root.
+	if _, err := basicFS.Stat(want); err != nil {
+		t.Errorf("Stat(%q) on a path returned by Walk failed: %s", want, err)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (C) 2014 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package fs defines the interface used to access folder contents. The
+// only implementation, BasicFilesystem, is rooted at a path on local
+// disk, and lib/config always gives a folder a BasicFilesystem; there is
+// no setting that selects another backend. Other implementations
+// (in-memory, chrooted, or backed by a remote protocol such as sftp or
+// S3) can satisfy the same Filesystem interface.
+package fs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Filesystem is the set of operations a folder needs performed against
+// its backing storage. All paths passed to its methods are relative to
+// the root the Filesystem was created with.
+type Filesystem interface {
+	Stat(name string) (os.FileInfo, error)
+	Create(name string) (File, error)
+	Mkdir(name string, perm os.FileMode) error
+	Rename(oldname, newname string) error
+	SyncDir(name string) error
+	HideFile(name string) error
+	Walk(root string, walkFn filepath.WalkFunc) error
+
+	// URI returns the location this Filesystem is backed by, e.g. a
+	// local path or a remote URI, for display and logging purposes.
+	URI() string
+}
+
+// File is the subset of *os.File that Filesystem implementations must be
+// able to hand back from Create.
+type File interface {
+	io.Closer
+	io.Reader
+	io.Writer
+}